@@ -0,0 +1,116 @@
+package chacha20poly1305
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	chachaRounds = 20
+	blockSize    = 64
+)
+
+// sigma is "expand 32-byte k" read as four little-endian uint32s, the
+// constant ChaCha20 uses to initialize the first four words of its state.
+var sigma = [4]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574}
+
+func quarterRound(a, b, c, d *uint32) {
+	*a += *b
+	*d ^= *a
+	*d = bits.RotateLeft32(*d, 16)
+	*c += *d
+	*b ^= *c
+	*b = bits.RotateLeft32(*b, 12)
+	*a += *b
+	*d ^= *a
+	*d = bits.RotateLeft32(*d, 8)
+	*c += *d
+	*b ^= *c
+	*b = bits.RotateLeft32(*b, 7)
+}
+
+// chachaBlock runs the ChaCha20 block function for the given key, 32-bit
+// counter, and 96-bit nonce, writing the 64-byte keystream block to out.
+func chachaBlock(out *[blockSize]byte, key [8]uint32, counter uint32, nonce [3]uint32) {
+	state := [16]uint32{
+		sigma[0], sigma[1], sigma[2], sigma[3],
+		key[0], key[1], key[2], key[3],
+		key[4], key[5], key[6], key[7],
+		counter, nonce[0], nonce[1], nonce[2],
+	}
+	working := state
+
+	for i := 0; i < chachaRounds; i += 2 {
+		quarterRound(&working[0], &working[4], &working[8], &working[12])
+		quarterRound(&working[1], &working[5], &working[9], &working[13])
+		quarterRound(&working[2], &working[6], &working[10], &working[14])
+		quarterRound(&working[3], &working[7], &working[11], &working[15])
+
+		quarterRound(&working[0], &working[5], &working[10], &working[15])
+		quarterRound(&working[1], &working[6], &working[11], &working[12])
+		quarterRound(&working[2], &working[7], &working[8], &working[13])
+		quarterRound(&working[3], &working[4], &working[9], &working[14])
+	}
+
+	for i := range working {
+		working[i] += state[i]
+	}
+
+	for i, w := range working {
+		binary.LittleEndian.PutUint32(out[i*4:], w)
+	}
+}
+
+// hChaCha20 runs the HChaCha20 subkey derivation function described in the
+// XChaCha20 draft, used to derive a fresh key from the first 16 bytes of an
+// XChaCha20 nonce.
+func hChaCha20(key [8]uint32, nonce [4]uint32) [8]uint32 {
+	state := [16]uint32{
+		sigma[0], sigma[1], sigma[2], sigma[3],
+		key[0], key[1], key[2], key[3],
+		key[4], key[5], key[6], key[7],
+		nonce[0], nonce[1], nonce[2], nonce[3],
+	}
+
+	for i := 0; i < chachaRounds; i += 2 {
+		quarterRound(&state[0], &state[4], &state[8], &state[12])
+		quarterRound(&state[1], &state[5], &state[9], &state[13])
+		quarterRound(&state[2], &state[6], &state[10], &state[14])
+		quarterRound(&state[3], &state[7], &state[11], &state[15])
+
+		quarterRound(&state[0], &state[5], &state[10], &state[15])
+		quarterRound(&state[1], &state[6], &state[11], &state[12])
+		quarterRound(&state[2], &state[7], &state[8], &state[13])
+		quarterRound(&state[3], &state[4], &state[9], &state[14])
+	}
+
+	var out [8]uint32
+	copy(out[:4], state[0:4])
+	copy(out[4:], state[12:16])
+
+	return out
+}
+
+// xorKeyStream XORs src with the ChaCha20 keystream for the given key,
+// nonce, and starting counter, writing the result to dst. dst and src may
+// overlap exactly.
+func xorKeyStream(dst, src []byte, key [8]uint32, nonce [3]uint32, counter uint32) {
+	var block [blockSize]byte
+
+	for len(src) > 0 {
+		chachaBlock(&block, key, counter, nonce)
+
+		n := len(src)
+		if n > blockSize {
+			n = blockSize
+		}
+
+		for i := 0; i < n; i++ {
+			dst[i] = src[i] ^ block[i]
+		}
+
+		dst = dst[n:]
+		src = src[n:]
+		counter++
+	}
+}
@@ -0,0 +1,248 @@
+// Package chacha20poly1305 implements the ChaCha20-Poly1305 AEAD construction
+// described in RFC 8439, built on top of this module's Poly1305
+// implementation.
+//
+// Unlike raw Poly1305, which forbids reusing a key across more than one
+// message, a chacha20poly1305 AEAD derives a fresh one-time Poly1305 key from
+// the ChaCha20 keystream for every nonce, so the same key may safely be used
+// to seal many messages as long as each nonce is only used once per key.
+package chacha20poly1305
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"hash"
+
+	"github.com/codahale/poly1305"
+)
+
+const (
+	// KeySize is the length of ChaCha20Poly1305 keys, in bytes.
+	KeySize = 32
+	// NonceSize is the length of ChaCha20Poly1305 nonces, in bytes.
+	NonceSize = 12
+	// NonceSizeX is the length of XChaCha20Poly1305 nonces, in bytes.
+	NonceSizeX = 24
+	// Overhead is the length of the authentication tag appended to the
+	// ciphertext.
+	Overhead = 16
+)
+
+var (
+	// ErrInvalidKey is returned when the provided key is not 256 bits long.
+	ErrInvalidKey = errors.New("chacha20poly1305: invalid key length")
+	// errOpen is returned when a ciphertext fails authentication.
+	errOpen = errors.New("chacha20poly1305: message authentication failed")
+)
+
+type aead struct {
+	key [8]uint32
+}
+
+// New returns a ChaCha20-Poly1305 AEAD, as described in RFC 8439, using a
+// 96-bit nonce. The key argument must be 256 bits long.
+func New(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	return &aead{key: keyWords(key)}, nil
+}
+
+func (a *aead) NonceSize() int {
+	return NonceSize
+}
+
+func (a *aead) Overhead() int {
+	return Overhead
+}
+
+func (a *aead) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != NonceSize {
+		panic("chacha20poly1305: invalid nonce size")
+	}
+
+	return seal(dst, a.key, nonce, plaintext, additionalData)
+}
+
+func (a *aead) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		panic("chacha20poly1305: invalid nonce size")
+	}
+	if len(ciphertext) < Overhead {
+		return nil, errOpen
+	}
+
+	return open(dst, a.key, nonce, ciphertext, additionalData)
+}
+
+// xaead is the XChaCha20-Poly1305 variant, which extends the nonce to 192
+// bits by using HChaCha20 to derive a fresh subkey for each nonce.
+type xaead struct {
+	key [8]uint32
+}
+
+// NewX returns an XChaCha20-Poly1305 AEAD using a 192-bit nonce. The key
+// argument must be 256 bits long.
+func NewX(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	return &xaead{key: keyWords(key)}, nil
+}
+
+func (a *xaead) NonceSize() int {
+	return NonceSizeX
+}
+
+func (a *xaead) Overhead() int {
+	return Overhead
+}
+
+func (a *xaead) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != NonceSizeX {
+		panic("chacha20poly1305: invalid nonce size")
+	}
+
+	subKey, subNonce := a.deriveKey(nonce)
+
+	return seal(dst, subKey, subNonce, plaintext, additionalData)
+}
+
+func (a *xaead) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSizeX {
+		panic("chacha20poly1305: invalid nonce size")
+	}
+	if len(ciphertext) < Overhead {
+		return nil, errOpen
+	}
+
+	subKey, subNonce := a.deriveKey(nonce)
+
+	return open(dst, subKey, subNonce, ciphertext, additionalData)
+}
+
+// deriveKey uses HChaCha20 to derive a subkey from the first 16 bytes of an
+// XChaCha20 nonce, and builds the 96-bit ChaCha20 nonce from the remaining 8
+// bytes, as described in the XChaCha20 draft.
+func (a *xaead) deriveKey(nonce []byte) ([8]uint32, []byte) {
+	var hNonce [4]uint32
+	for i := 0; i < 4; i++ {
+		hNonce[i] = binary.LittleEndian.Uint32(nonce[i*4:])
+	}
+
+	subKey := hChaCha20(a.key, hNonce)
+
+	subNonce := make([]byte, NonceSize)
+	copy(subNonce[4:], nonce[16:24])
+
+	return subKey, subNonce
+}
+
+func keyWords(key []byte) [8]uint32 {
+	var k [8]uint32
+	for i := range k {
+		k[i] = binary.LittleEndian.Uint32(key[i*4:])
+	}
+
+	return k
+}
+
+// seal encrypts plaintext with a one-time key derived from ChaCha20 block 0
+// and appends a Poly1305 tag covering additionalData and the ciphertext.
+//
+// seal panics if plaintext is too large for the 32-bit ChaCha20 block
+// counter to address without wrapping, per RFC 8439 section 2.8.
+func seal(dst []byte, key [8]uint32, nonce, plaintext, additionalData []byte) []byte {
+	if uint64(len(plaintext)) > (1<<38)-64 {
+		panic("chacha20poly1305: plaintext too large")
+	}
+
+	nonceWords := nonceWords(nonce)
+
+	var polyKeyBlock [blockSize]byte
+	chachaBlock(&polyKeyBlock, key, 0, nonceWords)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+Overhead)
+	ciphertext, tag := out[:len(plaintext)], out[len(plaintext):]
+
+	xorKeyStream(ciphertext, plaintext, key, nonceWords, 1)
+
+	mac, _ := poly1305.New(polyKeyBlock[:poly1305.KeySize])
+	writeMACData(mac, additionalData, ciphertext)
+	copy(tag, mac.Sum(nil))
+
+	return ret
+}
+
+// open verifies the Poly1305 tag and, if valid, decrypts ciphertext with a
+// one-time key derived from ChaCha20 block 0.
+//
+// open panics if ciphertext is too large for the 32-bit ChaCha20 block
+// counter to address without wrapping, per RFC 8439 section 2.8.
+func open(dst []byte, key [8]uint32, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if uint64(len(ciphertext)) > (1<<38)-48 {
+		panic("chacha20poly1305: ciphertext too large")
+	}
+
+	tag := ciphertext[len(ciphertext)-Overhead:]
+	ciphertext = ciphertext[:len(ciphertext)-Overhead]
+
+	nonceWords := nonceWords(nonce)
+
+	var polyKeyBlock [blockSize]byte
+	chachaBlock(&polyKeyBlock, key, 0, nonceWords)
+
+	mac, _ := poly1305.New(polyKeyBlock[:poly1305.KeySize])
+	writeMACData(mac, additionalData, ciphertext)
+
+	if subtle.ConstantTimeCompare(mac.Sum(nil), tag) != 1 {
+		return nil, errOpen
+	}
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	xorKeyStream(out, ciphertext, key, nonceWords, 1)
+
+	return ret, nil
+}
+
+func nonceWords(nonce []byte) [3]uint32 {
+	var n [3]uint32
+	for i := range n {
+		n[i] = binary.LittleEndian.Uint32(nonce[i*4:])
+	}
+
+	return n
+}
+
+// writeMACData feeds additionalData and ciphertext, each padded to a 16-byte
+// boundary, followed by their little-endian 64-bit lengths, into mac, per
+// RFC 8439 section 2.8.
+func writeMACData(mac hash.Hash, additionalData, ciphertext []byte) {
+	poly1305.WriteWithPadding(mac, additionalData)
+	poly1305.WriteWithPadding(mac, ciphertext)
+
+	var lens [16]byte
+	binary.LittleEndian.PutUint64(lens[0:8], uint64(len(additionalData)))
+	binary.LittleEndian.PutUint64(lens[8:16], uint64(len(ciphertext)))
+	mac.Write(lens[:])
+}
+
+// sliceForAppend extends in by n bytes, returning the full slice and the
+// newly appended tail, as used by cipher.AEAD implementations in the
+// standard library.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+
+	tail = head[len(in):]
+
+	return
+}
@@ -0,0 +1,96 @@
+package chacha20poly1305
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// stolen from RFC 8439, section 2.8.2
+func TestChaCha20Poly1305(t *testing.T) {
+	key, err := hex.DecodeString("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce, err := hex.DecodeString("070000004041424344454647")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aad, err := hex.DecodeString("50515253c0c1c2c3c4c5c6c7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")
+
+	expected, err := hex.DecodeString(
+		"d31a8d34648e60db7b86afbc53ef7ec2" +
+			"a4aded51296e08fea9e2b5a736ee62d6" +
+			"3dbea45e8ca9671282fafb69da92728b" +
+			"1a71de0a9e060b2905d6a5b67ecd3b36" +
+			"92ddbd7f2d778b8c9803aee328091b58" +
+			"fab324e4fad675945585808b4831d7bc" +
+			"3ff4def08e4b7a9de576d26586cec64b" +
+			"6116" +
+			"1ae10b594f09e26a7e902ecbd0600691",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed := a.Seal(nil, nonce, plaintext, aad)
+	if !bytes.Equal(expected, sealed) {
+		t.Errorf("Bad ciphertext: expected %x, was %x", expected, sealed)
+	}
+
+	opened, err := a.Open(nil, nonce, sealed, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, opened) {
+		t.Errorf("Bad plaintext: expected %x, was %x", plaintext, opened)
+	}
+
+	tampered := append([]byte{}, sealed...)
+	tampered[0] ^= 1
+	if _, err := a.Open(nil, nonce, tampered, aad); err == nil {
+		t.Error("Open succeeded on tampered ciphertext")
+	}
+}
+
+func TestXChaCha20Poly1305RoundTrip(t *testing.T) {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	nonce := make([]byte, NonceSizeX)
+	for i := range nonce {
+		nonce[i] = byte(i + 1)
+	}
+
+	aad := []byte("additional data")
+	plaintext := []byte("a message encrypted with XChaCha20-Poly1305")
+
+	a, err := NewX(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed := a.Seal(nil, nonce, plaintext, aad)
+
+	opened, err := a.Open(nil, nonce, sealed, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, opened) {
+		t.Errorf("Bad plaintext: expected %x, was %x", plaintext, opened)
+	}
+}
@@ -8,16 +8,14 @@
 // the same key. Authenticators for two messages under the same key should be
 // expected to reveal enough information to allow forgeries of authenticators
 // on other messages.
+//
+// On amd64 with cgo enabled, the MAC is computed using floodyberry's cgo/asm
+// implementation. Everywhere else — other architectures, or amd64 built with
+// CGO_ENABLED=0 — a pure Go implementation is used instead.
 package poly1305
 
-// BUG(codahale): Only supports AMD64.
-
-// +build: amd64
-
 import (
-	// #cgo CFLAGS: -O3
-	// #include "poly1305-donna.h"
-	"C"
+	"crypto/subtle"
 	"errors"
 	"hash"
 )
@@ -25,8 +23,16 @@ import (
 var (
 	// ErrInvalidKey is returned when the provided key is not 256 bits long.
 	ErrInvalidKey = errors.New("poly1305: invalid key length")
+	// ErrInvalidState is returned by UnmarshalBinary when given data that is
+	// not a validly marshaled Poly1305 state for the build it's unmarshaled
+	// on.
+	ErrInvalidState = errors.New("poly1305: invalid marshaled state")
 )
 
+// marshaledVersion is the version byte prefixed to a Poly1305's marshaled
+// state, bumped whenever the on-disk format changes incompatibly.
+const marshaledVersion = 1
+
 const (
 	// KeySize is the length of Poly1305 keys, in bytes.
 	KeySize = 32
@@ -36,12 +42,6 @@ const (
 	Size = 16
 )
 
-// A Poly1305 is an instance of Poly1305 using a particular key.
-type Poly1305 struct {
-	key   []byte
-	state C.poly1305_state
-}
-
 // New creates and returns a keyed Hash implementation. The key argument must be
 // 256 bits long, the value of which must only be used once.
 func New(key []byte) (hash.Hash, error) {
@@ -49,55 +49,67 @@ func New(key []byte) (hash.Hash, error) {
 		return nil, ErrInvalidKey
 	}
 
-	h := new(Poly1305)
-	h.key = make([]byte, KeySize)
-	copy(h.key, key)
-	h.Reset()
-
-	return h, nil
+	return newMAC(key), nil
 }
 
-// BlockSize returns the hash's underlying block size.
-// The Write method must be able to accept any amount
-// of data, but it may operate more efficiently if all writes
-// are a multiple of the block size.
-func (*Poly1305) BlockSize() int {
-	return BlockSize
-}
+// A MAC is a streaming Poly1305 MAC. It implements hash.Hash, plus a Verify
+// method for constant-time tag comparison.
+type MAC = Poly1305
+
+// NewMAC creates and returns a *MAC using the given key, the value of which
+// must only be used once. The key argument must be 256 bits long.
+func NewMAC(key []byte) (*MAC, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
 
-// Size returns the number of bytes Sum will return.
-func (*Poly1305) Size() int {
-	return Size
+	return newMAC(key), nil
 }
 
-// Reset resets the Hash to its initial state.
-func (s *Poly1305) Reset() {
-	C.poly1305_init(&s.state, (*C.uchar)(&s.key[0]))
+// Verify reports whether mac is a valid Poly1305 tag for the data written to
+// s since its creation or last Reset, using a constant-time comparison. It
+// does not change the underlying hash state.
+func (s *Poly1305) Verify(mac []byte) bool {
+	return subtle.ConstantTimeCompare(s.Sum(nil), mac) == 1
 }
 
-// Write (via the embedded io.Writer interface) adds more data to the running
-// hash. It never returns an error.
-func (s *Poly1305) Write(buf []byte) (int, error) {
-	var p *C.uchar
-	if len(buf) > 0 {
-		p = (*C.uchar)(&buf[0])
+// Sum generates an authenticator for msg using a one-time key and puts the
+// 16-byte result into out. Authenticating two different messages with the
+// same key allows an attacker to forge authenticators for other messages
+// under that key.
+//
+// Sum panics with ErrInvalidKey if key is not KeySize bytes long.
+func Sum(out *[16]byte, msg, key []byte) {
+	if len(key) != KeySize {
+		panic(ErrInvalidKey)
 	}
 
-	C.poly1305_update(&s.state, p, (C.size_t)(len(buf)))
-
-	return len(buf), nil
+	m := newMAC(key)
+	m.Write(msg)
+	copy(out[:], m.Sum(nil))
 }
 
-// Sum appends the current hash to b and returns the resulting slice.
-// It does not change the underlying hash state.
-func (s *Poly1305) Sum(buf []byte) []byte {
-	if len(buf) < s.Size() {
-		buf = make([]byte, s.Size())
-	} else {
-		buf = buf[0:s.Size()]
-	}
+// Verify reports whether mac is a valid Poly1305 tag for msg under key,
+// using a constant-time comparison.
+//
+// Verify panics with ErrInvalidKey if key is not KeySize bytes long.
+func Verify(mac *[16]byte, msg, key []byte) bool {
+	var sum [16]byte
+	Sum(&sum, msg, key)
 
-	C.poly1305_finish(&s.state, (*C.uchar)(&buf[0]))
+	return subtle.ConstantTimeCompare(sum[:], mac[:]) == 1
+}
 
-	return buf
+// WriteWithPadding writes b to m, then writes as many zero bytes as needed
+// to bring the number of bytes written by this call up to a multiple of
+// BlockSize. This is the padding rule RFC 7539-style AEAD constructions (and
+// SSH's chacha20-poly1305 transport cipher) require around additional data
+// and ciphertext before the lengths are appended and the tag is finalized,
+// sparing callers from buffering and padding by hand.
+func WriteWithPadding(m hash.Hash, b []byte) {
+	m.Write(b)
+
+	if rem := len(b) % BlockSize; rem != 0 {
+		m.Write(make([]byte, BlockSize-rem))
+	}
 }
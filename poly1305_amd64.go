@@ -0,0 +1,105 @@
+//go:build amd64 && cgo
+// +build amd64,cgo
+
+package poly1305
+
+import (
+	// #cgo CFLAGS: -O3
+	// #include "poly1305-donna.h"
+	"C"
+	"unsafe"
+)
+
+// A Poly1305 is an instance of Poly1305 using a particular key.
+type Poly1305 struct {
+	key   []byte
+	state C.poly1305_state
+}
+
+// newMAC returns a Poly1305 backed by floodyberry's cgo/asm implementation.
+func newMAC(key []byte) *Poly1305 {
+	h := new(Poly1305)
+	h.key = make([]byte, KeySize)
+	copy(h.key, key)
+	h.Reset()
+
+	return h
+}
+
+// BlockSize returns the hash's underlying block size.
+// The Write method must be able to accept any amount
+// of data, but it may operate more efficiently if all writes
+// are a multiple of the block size.
+func (*Poly1305) BlockSize() int {
+	return BlockSize
+}
+
+// Size returns the number of bytes Sum will return.
+func (*Poly1305) Size() int {
+	return Size
+}
+
+// Reset resets the Hash to its initial state.
+func (s *Poly1305) Reset() {
+	C.poly1305_init(&s.state, (*C.uchar)(&s.key[0]))
+}
+
+// Write (via the embedded io.Writer interface) adds more data to the running
+// hash. It never returns an error.
+func (s *Poly1305) Write(buf []byte) (int, error) {
+	var p *C.uchar
+	if len(buf) > 0 {
+		p = (*C.uchar)(&buf[0])
+	}
+
+	C.poly1305_update(&s.state, p, (C.size_t)(len(buf)))
+
+	return len(buf), nil
+}
+
+// Sum appends the current hash to b and returns the resulting slice.
+// It does not change the underlying hash state.
+func (s *Poly1305) Sum(buf []byte) []byte {
+	if len(buf) < s.Size() {
+		buf = make([]byte, s.Size())
+	} else {
+		buf = buf[0:s.Size()]
+	}
+
+	C.poly1305_finish(&s.state, (*C.uchar)(&buf[0]))
+
+	return buf
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, capturing the key and
+// the donna implementation's opaque internal state (accumulator, clamped
+// r/pad, and any buffered partial block) so the MAC can be resumed later
+// with UnmarshalBinary.
+func (s *Poly1305) MarshalBinary() ([]byte, error) {
+	raw := (*[unsafe.Sizeof(s.state)]byte)(unsafe.Pointer(&s.state))[:]
+
+	out := make([]byte, 1+KeySize+len(raw))
+	out[0] = marshaledVersion
+	copy(out[1:], s.key)
+	copy(out[1+KeySize:], raw)
+
+	return out, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It rejects data
+// that isn't exactly the size this build marshals, or that was marshaled
+// with an incompatible version.
+func (s *Poly1305) UnmarshalBinary(data []byte) error {
+	want := 1 + KeySize + int(unsafe.Sizeof(s.state))
+	if len(data) != want || data[0] != marshaledVersion {
+		return ErrInvalidState
+	}
+
+	s.key = make([]byte, KeySize)
+	copy(s.key, data[1:1+KeySize])
+
+	raw := (*[unsafe.Sizeof(s.state)]byte)(unsafe.Pointer(&s.state))[:]
+	copy(raw, data[1+KeySize:])
+
+	return nil
+}
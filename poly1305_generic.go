@@ -0,0 +1,326 @@
+//go:build !amd64 || !cgo
+// +build !amd64 !cgo
+
+package poly1305
+
+import "encoding/binary"
+
+// A Poly1305 is a pure Go implementation of Poly1305, used on architectures
+// without an optimized cgo/asm implementation. It accumulates the MAC as
+// five 26-bit limbs (the standard radix-2^26 representation used by
+// reference Poly1305 implementations), using only fixed-width uint32/uint64
+// arithmetic and branchless selection — no data-dependent control flow or
+// variable-time bignum division — so its timing behavior doesn't depend on
+// the key or message.
+type Poly1305 struct {
+	key  []byte
+	r    [5]uint32
+	h    [5]uint32
+	pad  [4]uint32
+	buf  [BlockSize]byte
+	nBuf int
+}
+
+// newMAC returns a Poly1305 backed by a pure Go 130-bit prime field
+// accumulator.
+func newMAC(key []byte) *Poly1305 {
+	h := new(Poly1305)
+	h.key = make([]byte, KeySize)
+	copy(h.key, key)
+	h.Reset()
+
+	return h
+}
+
+// BlockSize returns the hash's underlying block size.
+// The Write method must be able to accept any amount
+// of data, but it may operate more efficiently if all writes
+// are a multiple of the block size.
+func (*Poly1305) BlockSize() int {
+	return BlockSize
+}
+
+// Size returns the number of bytes Sum will return.
+func (*Poly1305) Size() int {
+	return Size
+}
+
+// Reset resets the Hash to its initial state.
+func (s *Poly1305) Reset() {
+	key := s.key
+
+	// r &= 0xffffffc0ffffffc0ffffffc0fffffff, split into five 26-bit limbs.
+	s.r[0] = binary.LittleEndian.Uint32(key[0:]) & 0x3ffffff
+	s.r[1] = (binary.LittleEndian.Uint32(key[3:]) >> 2) & 0x3ffff03
+	s.r[2] = (binary.LittleEndian.Uint32(key[6:]) >> 4) & 0x3ffc0ff
+	s.r[3] = (binary.LittleEndian.Uint32(key[9:]) >> 6) & 0x3f03fff
+	s.r[4] = (binary.LittleEndian.Uint32(key[12:]) >> 8) & 0x00fffff
+
+	s.h = [5]uint32{}
+
+	s.pad[0] = binary.LittleEndian.Uint32(key[16:])
+	s.pad[1] = binary.LittleEndian.Uint32(key[20:])
+	s.pad[2] = binary.LittleEndian.Uint32(key[24:])
+	s.pad[3] = binary.LittleEndian.Uint32(key[28:])
+
+	s.nBuf = 0
+}
+
+// Write (via the embedded io.Writer interface) adds more data to the running
+// hash. It never returns an error.
+func (s *Poly1305) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if s.nBuf > 0 {
+		want := BlockSize - s.nBuf
+		if want > len(p) {
+			want = len(p)
+		}
+
+		copy(s.buf[s.nBuf:], p[:want])
+		s.nBuf += want
+		p = p[want:]
+
+		if s.nBuf < BlockSize {
+			return n, nil
+		}
+
+		poly1305Blocks(&s.h, s.r, s.buf[:], false)
+		s.nBuf = 0
+	}
+
+	if want := (len(p) / BlockSize) * BlockSize; want > 0 {
+		poly1305Blocks(&s.h, s.r, p[:want], false)
+		p = p[want:]
+	}
+
+	if len(p) > 0 {
+		s.nBuf = copy(s.buf[:], p)
+	}
+
+	return n, nil
+}
+
+// Sum appends the current hash to b and returns the resulting slice.
+// It does not change the underlying hash state.
+func (s *Poly1305) Sum(buf []byte) []byte {
+	if len(buf) < s.Size() {
+		buf = make([]byte, s.Size())
+	} else {
+		buf = buf[0:s.Size()]
+	}
+
+	h := s.h
+
+	if s.nBuf > 0 {
+		var block [BlockSize]byte
+		copy(block[:], s.buf[:s.nBuf])
+		block[s.nBuf] = 1
+
+		poly1305Blocks(&h, s.r, block[:], true)
+	}
+
+	tag := poly1305Finish(h, s.pad)
+	copy(buf, tag[:])
+
+	return buf
+}
+
+// poly1305Blocks absorbs one or more 16-byte blocks of m (len(m) must be a
+// multiple of BlockSize) into h, using the clamped multiplier r. finalBlock
+// must be true only when m is the last, padded block of a message whose
+// length isn't a multiple of BlockSize, per the Poly1305 spec's rule that
+// the implicit high bit is appended immediately after the message rather
+// than fixed at bit 128.
+func poly1305Blocks(h *[5]uint32, r [5]uint32, m []byte, finalBlock bool) {
+	hibit := uint32(1 << 24)
+	if finalBlock {
+		hibit = 0
+	}
+
+	r0, r1, r2, r3, r4 := r[0], r[1], r[2], r[3], r[4]
+	s1, s2, s3, s4 := r1*5, r2*5, r3*5, r4*5
+	h0, h1, h2, h3, h4 := h[0], h[1], h[2], h[3], h[4]
+
+	for len(m) >= BlockSize {
+		t0 := binary.LittleEndian.Uint32(m[0:])
+		t1 := binary.LittleEndian.Uint32(m[3:])
+		t2 := binary.LittleEndian.Uint32(m[6:])
+		t3 := binary.LittleEndian.Uint32(m[9:])
+		t4 := binary.LittleEndian.Uint32(m[12:])
+
+		h0 += t0 & 0x3ffffff
+		h1 += (t1 >> 2) & 0x3ffffff
+		h2 += (t2 >> 4) & 0x3ffffff
+		h3 += (t3 >> 6) & 0x3ffffff
+		h4 += (t4 >> 8) | hibit
+
+		// h *= r, accumulated in 64-bit limbs to avoid overflow.
+		d0 := uint64(h0)*uint64(r0) + uint64(h1)*uint64(s4) + uint64(h2)*uint64(s3) + uint64(h3)*uint64(s2) + uint64(h4)*uint64(s1)
+		d1 := uint64(h0)*uint64(r1) + uint64(h1)*uint64(r0) + uint64(h2)*uint64(s4) + uint64(h3)*uint64(s3) + uint64(h4)*uint64(s2)
+		d2 := uint64(h0)*uint64(r2) + uint64(h1)*uint64(r1) + uint64(h2)*uint64(r0) + uint64(h3)*uint64(s4) + uint64(h4)*uint64(s3)
+		d3 := uint64(h0)*uint64(r3) + uint64(h1)*uint64(r2) + uint64(h2)*uint64(r1) + uint64(h3)*uint64(r0) + uint64(h4)*uint64(s4)
+		d4 := uint64(h0)*uint64(r4) + uint64(h1)*uint64(r3) + uint64(h2)*uint64(r2) + uint64(h3)*uint64(r1) + uint64(h4)*uint64(r0)
+
+		// Partial reduction mod 2^130-5, carrying between limbs.
+		c := uint32(d0 >> 26)
+		h0 = uint32(d0) & 0x3ffffff
+		d1 += uint64(c)
+		c = uint32(d1 >> 26)
+		h1 = uint32(d1) & 0x3ffffff
+		d2 += uint64(c)
+		c = uint32(d2 >> 26)
+		h2 = uint32(d2) & 0x3ffffff
+		d3 += uint64(c)
+		c = uint32(d3 >> 26)
+		h3 = uint32(d3) & 0x3ffffff
+		d4 += uint64(c)
+		c = uint32(d4 >> 26)
+		h4 = uint32(d4) & 0x3ffffff
+		h0 += c * 5
+		c = h0 >> 26
+		h0 &= 0x3ffffff
+		h1 += c
+
+		m = m[BlockSize:]
+	}
+
+	h[0], h[1], h[2], h[3], h[4] = h0, h1, h2, h3, h4
+}
+
+// poly1305Finish fully reduces h mod 2^130-5, reduces it again mod 2^128,
+// adds pad mod 2^128, and serializes the result little-endian. It takes h
+// and pad by value so callers can finalize without mutating their state.
+func poly1305Finish(h [5]uint32, pad [4]uint32) [16]byte {
+	h0, h1, h2, h3, h4 := h[0], h[1], h[2], h[3], h[4]
+
+	// Fully carry h.
+	c := h1 >> 26
+	h1 &= 0x3ffffff
+	h2 += c
+	c = h2 >> 26
+	h2 &= 0x3ffffff
+	h3 += c
+	c = h3 >> 26
+	h3 &= 0x3ffffff
+	h4 += c
+	c = h4 >> 26
+	h4 &= 0x3ffffff
+	h0 += c * 5
+	c = h0 >> 26
+	h0 &= 0x3ffffff
+	h1 += c
+
+	// Compute h + -p (i.e. h - (2^130-5)) and branchlessly select it over h
+	// whenever h >= 2^130-5.
+	g0 := h0 + 5
+	c = g0 >> 26
+	g0 &= 0x3ffffff
+	g1 := h1 + c
+	c = g1 >> 26
+	g1 &= 0x3ffffff
+	g2 := h2 + c
+	c = g2 >> 26
+	g2 &= 0x3ffffff
+	g3 := h3 + c
+	c = g3 >> 26
+	g3 &= 0x3ffffff
+	g4 := h4 + c - (1 << 26)
+
+	mask := (g4 >> 31) - 1
+	g0 &= mask
+	g1 &= mask
+	g2 &= mask
+	g3 &= mask
+	g4 &= mask
+	mask = ^mask
+	h0 = (h0 & mask) | g0
+	h1 = (h1 & mask) | g1
+	h2 = (h2 & mask) | g2
+	h3 = (h3 & mask) | g3
+	h4 = (h4 & mask) | g4
+
+	// h = h mod 2^128, repacked from five 26-bit limbs into four 32-bit words.
+	h0 = (h0 | (h1 << 26)) & 0xffffffff
+	h1 = ((h1 >> 6) | (h2 << 20)) & 0xffffffff
+	h2 = ((h2 >> 12) | (h3 << 14)) & 0xffffffff
+	h3 = ((h3 >> 18) | (h4 << 8)) & 0xffffffff
+
+	// mac = (h + pad) mod 2^128.
+	f := uint64(h0) + uint64(pad[0])
+	h0 = uint32(f)
+	f = uint64(h1) + uint64(pad[1]) + (f >> 32)
+	h1 = uint32(f)
+	f = uint64(h2) + uint64(pad[2]) + (f >> 32)
+	h2 = uint32(f)
+	f = uint64(h3) + uint64(pad[3]) + (f >> 32)
+	h3 = uint32(f)
+
+	var out [16]byte
+	binary.LittleEndian.PutUint32(out[0:], h0)
+	binary.LittleEndian.PutUint32(out[4:], h1)
+	binary.LittleEndian.PutUint32(out[8:], h2)
+	binary.LittleEndian.PutUint32(out[12:], h3)
+
+	return out
+}
+
+// marshaledHeaderSize is the length of a marshaled Poly1305's fixed-size
+// fields: the version byte, the original key (32 bytes, so Reset keeps
+// working after a resume), r (five uint32 limbs), h (five uint32 limbs),
+// pad (four uint32 words), and the buffered-length byte.
+const marshaledHeaderSize = 1 + KeySize + 4*5 + 4*5 + 4*4 + 1
+
+// MarshalBinary implements encoding.BinaryMarshaler, capturing the key, the
+// clamped r, the current accumulator, the pad, and any buffered partial
+// block so the MAC can be resumed later with UnmarshalBinary.
+func (s *Poly1305) MarshalBinary() ([]byte, error) {
+	out := make([]byte, marshaledHeaderSize+s.nBuf)
+	out[0] = marshaledVersion
+	copy(out[1:33], s.key)
+	putUint32s(out[33:53], s.r[:])
+	putUint32s(out[53:73], s.h[:])
+	putUint32s(out[73:89], s.pad[:])
+	out[89] = byte(s.nBuf)
+	copy(out[marshaledHeaderSize:], s.buf[:s.nBuf])
+
+	return out, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It rejects data
+// that isn't a validly sized marshaled state, or that was marshaled with an
+// incompatible version.
+func (s *Poly1305) UnmarshalBinary(data []byte) error {
+	if len(data) < marshaledHeaderSize || data[0] != marshaledVersion {
+		return ErrInvalidState
+	}
+
+	n := int(data[89])
+	if n >= BlockSize || len(data) != marshaledHeaderSize+n {
+		return ErrInvalidState
+	}
+
+	s.key = append(s.key[:0], data[1:33]...)
+	getUint32s(s.r[:], data[33:53])
+	getUint32s(s.h[:], data[53:73])
+	getUint32s(s.pad[:], data[73:89])
+	s.nBuf = copy(s.buf[:], data[marshaledHeaderSize:])
+
+	return nil
+}
+
+// putUint32s writes each of src little-endian into consecutive 4-byte
+// chunks of dst, which must be exactly 4*len(src) bytes long.
+func putUint32s(dst []byte, src []uint32) {
+	for i, v := range src {
+		binary.LittleEndian.PutUint32(dst[i*4:], v)
+	}
+}
+
+// getUint32s reads len(dst) little-endian uint32s from consecutive 4-byte
+// chunks of src into dst.
+func getUint32s(dst []uint32, src []byte) {
+	for i := range dst {
+		dst[i] = binary.LittleEndian.Uint32(src[i*4:])
+	}
+}
@@ -3,7 +3,6 @@ package poly1305
 import (
 	"bytes"
 	"crypto/rand"
-	"crypto/subtle"
 	"encoding/hex"
 	"io"
 	"testing"
@@ -64,6 +63,160 @@ func TestPoly1305(t *testing.T) {
 	}
 }
 
+func TestSum(t *testing.T) {
+	for i, vector := range testVectors {
+		t.Logf("Running test vector %d", i)
+
+		input, err := hex.DecodeString(vector[0])
+		if err != nil {
+			t.Error(err)
+		}
+
+		key, err := hex.DecodeString(vector[1])
+		if err != nil {
+			t.Error(err)
+		}
+
+		expected, err := hex.DecodeString(vector[2])
+		if err != nil {
+			t.Error(err)
+		}
+
+		var actual [16]byte
+		Sum(&actual, input, key)
+
+		if !bytes.Equal(expected, actual[:]) {
+			t.Errorf("Bad MAC: expected %x, was %x", expected, actual)
+		}
+
+		var mac [16]byte
+		copy(mac[:], expected)
+		if !Verify(&mac, input, key) {
+			t.Errorf("Verify failed for test vector %d", i)
+		}
+
+		mac[0] ^= 1
+		if Verify(&mac, input, key) {
+			t.Errorf("Verify succeeded for tampered tag on test vector %d", i)
+		}
+	}
+}
+
+func TestWriteWithPadding(t *testing.T) {
+	key := make([]byte, KeySize)
+
+	padded, _ := NewMAC(key)
+	WriteWithPadding(padded, []byte("abc"))
+
+	manual, _ := NewMAC(key)
+	manual.Write([]byte("abc"))
+	manual.Write(make([]byte, BlockSize-3))
+
+	if !bytes.Equal(padded.Sum(nil), manual.Sum(nil)) {
+		t.Error("WriteWithPadding did not pad a partial block correctly")
+	}
+
+	aligned, _ := NewMAC(key)
+	WriteWithPadding(aligned, make([]byte, BlockSize))
+
+	unpadded, _ := NewMAC(key)
+	unpadded.Write(make([]byte, BlockSize))
+
+	if !bytes.Equal(aligned.Sum(nil), unpadded.Sum(nil)) {
+		t.Error("WriteWithPadding padded an already block-aligned write")
+	}
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	message := []byte("a message written in two parts, checkpointed in between")
+
+	want, err := NewMAC(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Write(message)
+	wantSum := want.Sum(nil)
+
+	live, err := NewMAC(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live.Write(message[:10])
+
+	data, err := live.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := NewMAC(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resumed.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	resumed.Write(message[10:])
+
+	if gotSum := resumed.Sum(nil); !bytes.Equal(wantSum, gotSum) {
+		t.Errorf("resumed MAC: expected %x, was %x", wantSum, gotSum)
+	}
+
+	truncated := data[:len(data)-1]
+	if err := resumed.UnmarshalBinary(truncated); err != ErrInvalidState {
+		t.Errorf("UnmarshalBinary(truncated) = %v, want %v", err, ErrInvalidState)
+	}
+
+	badVersion := append([]byte{}, data...)
+	badVersion[0] ^= 0xff
+	if err := resumed.UnmarshalBinary(badVersion); err != ErrInvalidState {
+		t.Errorf("UnmarshalBinary(badVersion) = %v, want %v", err, ErrInvalidState)
+	}
+}
+
+func TestUnmarshalBinaryThenReset(t *testing.T) {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	live, err := NewMAC(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live.Write([]byte("some data"))
+
+	data, err := live.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A zero-value Poly1305, as opposed to one built via NewMAC, must still
+	// come out of UnmarshalBinary in a state where Reset doesn't panic or
+	// silently reseed with the wrong key.
+	var fresh Poly1305
+	if err := fresh.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	fresh.Reset()
+
+	fresh.Write([]byte("a message authenticated entirely after Reset"))
+
+	want, err := NewMAC(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Write([]byte("a message authenticated entirely after Reset"))
+
+	if gotSum, wantSum := fresh.Sum(nil), want.Sum(nil); !bytes.Equal(gotSum, wantSum) {
+		t.Errorf("Reset after UnmarshalBinary: expected %x, was %x", wantSum, gotSum)
+	}
+}
+
 func ExampleNew() {
 	// A message for which we'd like to ensure authenticity.
 	message := []byte("A message which must be authentic.")
@@ -90,18 +243,16 @@ func ExampleNew() {
 	sender.Write(message)
 	sent := sender.Sum(nil)
 
-	// The receiver calculates the MAC for the message it received.
-	receiver, err := New(key)
+	// The receiver calculates the MAC for the message it received and
+	// verifies it against the one the sender calculated, using a
+	// constant-time comparison to prevent timing attacks.
+	receiver, err := NewMAC(key)
 	if err != nil {
 		panic(err)
 	}
 	receiver.Write(message)
-	received := receiver.Sum(nil)
 
-	// The receiver compares the two MACs (using a constant-time comparison
-	// algorithm to prevent timing attacks), and iff they match is assured of
-	// the message's authenticity.
-	if subtle.ConstantTimeCompare(sent, received) != 1 {
+	if !receiver.Verify(sent) {
 		panic("Invalid message! Don't decrypt, process, or look at it.")
 	}
 }